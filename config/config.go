@@ -0,0 +1,139 @@
+// Package config loads the simple-server configuration from a YAML or TOML
+// file, providing defaults for every field so an empty or partial file is
+// enough to get started.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the top-level configuration for all four services plus the
+// shared HTTP listener they publish diagnostics on.
+type Config struct {
+	HTTP HTTPConfig `yaml:"http" toml:"http"`
+	DNS  DNSConfig  `yaml:"dns" toml:"dns"`
+	ICMP ICMPConfig `yaml:"icmp" toml:"icmp"`
+	SMTP SMTPConfig `yaml:"smtp" toml:"smtp"`
+}
+
+// HTTPConfig configures the HTTP(S) listener serving "/", "/upload",
+// "/metrics", and "/mailbox".
+type HTTPConfig struct {
+	Enabled     bool   `yaml:"enabled" toml:"enabled"`
+	Addr        string `yaml:"addr" toml:"addr"`
+	Hostname    string `yaml:"hostname" toml:"hostname"`
+	TLSAddr     string `yaml:"tls_addr" toml:"tls_addr"`
+	TLSCertFile string `yaml:"tls_cert_file" toml:"tls_cert_file"`
+	TLSKeyFile  string `yaml:"tls_key_file" toml:"tls_key_file"`
+	HTTP2       bool   `yaml:"http2" toml:"http2"`
+	UploadDir   string `yaml:"upload_dir" toml:"upload_dir"`
+}
+
+// DNSConfig configures the authoritative DNS responder.
+type DNSConfig struct {
+	Enabled  bool   `yaml:"enabled" toml:"enabled"`
+	Addr     string `yaml:"addr" toml:"addr"`
+	ZoneFile string `yaml:"zone_file" toml:"zone_file"`
+	Upstream string `yaml:"upstream" toml:"upstream"`
+}
+
+// ICMPMode selects whether the ICMP echo server binds a raw socket
+// (privileged) or a UDP datagram socket (unprivileged).
+type ICMPMode string
+
+const (
+	ICMPModePrivileged   ICMPMode = "privileged"
+	ICMPModeUnprivileged ICMPMode = "unprivileged"
+)
+
+// ICMPConfig configures the ICMP echo server.
+type ICMPConfig struct {
+	Enabled bool     `yaml:"enabled" toml:"enabled"`
+	Mode    ICMPMode `yaml:"mode" toml:"mode"`
+	Bind    string   `yaml:"bind" toml:"bind"`
+}
+
+// SMTPConfig configures the SMTP mail sink.
+type SMTPConfig struct {
+	Enabled        bool   `yaml:"enabled" toml:"enabled"`
+	Addr           string `yaml:"addr" toml:"addr"`
+	SubmissionAddr string `yaml:"submission_addr" toml:"submission_addr"`
+	Hostname       string `yaml:"hostname" toml:"hostname"`
+	Banner         string `yaml:"banner" toml:"banner"`
+	MaildirPath    string `yaml:"maildir_path" toml:"maildir_path"`
+	TLSCertFile    string `yaml:"tls_cert_file" toml:"tls_cert_file"`
+	TLSKeyFile     string `yaml:"tls_key_file" toml:"tls_key_file"`
+}
+
+// Default returns the configuration matching simple-server's historical
+// hard-coded behavior, used when no config file is given.
+func Default() *Config {
+	return &Config{
+		HTTP: HTTPConfig{
+			Enabled:  true,
+			Addr:     ":8080",
+			Hostname: "simple-server.local",
+		},
+		DNS: DNSConfig{
+			Enabled: true,
+			Addr:    ":53",
+		},
+		ICMP: ICMPConfig{
+			Enabled: true,
+			Mode:    ICMPModeUnprivileged,
+			Bind:    "0.0.0.0",
+		},
+		SMTP: SMTPConfig{
+			Enabled:        true,
+			Addr:           ":25",
+			SubmissionAddr: ":587",
+			Hostname:       "simple-server.local",
+			Banner:         "simple-server ESMTP ready",
+		},
+	}
+}
+
+// Load reads a YAML (.yaml/.yml) or TOML (.toml) file at path and merges it
+// onto Default(), so any field the file omits keeps its default value.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: read %s: %w", path, err)
+	}
+
+	cfg := Default()
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("config: parse yaml: %w", err)
+		}
+	case ".toml":
+		if err := toml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("config: parse toml: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("config: unrecognized config extension %q (want .yaml, .yml, or .toml)", ext)
+	}
+	return cfg, nil
+}
+
+// Validate checks the config for values that would fail at startup anyway,
+// so -config.check can catch them without binding any sockets.
+func (c *Config) Validate() error {
+	if c.ICMP.Mode != ICMPModePrivileged && c.ICMP.Mode != ICMPModeUnprivileged {
+		return fmt.Errorf("config: icmp.mode must be %q or %q, got %q", ICMPModePrivileged, ICMPModeUnprivileged, c.ICMP.Mode)
+	}
+	if (c.HTTP.TLSCertFile == "") != (c.HTTP.TLSKeyFile == "") {
+		return fmt.Errorf("config: http.tls_cert_file and http.tls_key_file must both be set or both be empty")
+	}
+	if (c.SMTP.TLSCertFile == "") != (c.SMTP.TLSKeyFile == "") {
+		return fmt.Errorf("config: smtp.tls_cert_file and smtp.tls_key_file must both be set or both be empty")
+	}
+	return nil
+}
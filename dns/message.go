@@ -0,0 +1,291 @@
+package dns
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Record types supported by the zone loader and message codec.
+const (
+	TypeA     uint16 = 1
+	TypeNS    uint16 = 2
+	TypeCNAME uint16 = 5
+	TypeSOA   uint16 = 6
+	TypePTR   uint16 = 12
+	TypeMX    uint16 = 15
+	TypeTXT   uint16 = 16
+	TypeAAAA  uint16 = 28
+
+	ClassIN uint16 = 1
+)
+
+// Response codes, per RFC 1035 section 4.1.1.
+const (
+	RCodeSuccess        uint16 = 0
+	RCodeFormatError    uint16 = 1
+	RCodeServerFailure  uint16 = 2
+	RCodeNameError      uint16 = 3 // NXDOMAIN
+	RCodeNotImplemented uint16 = 4
+	RCodeRefused        uint16 = 5
+)
+
+var errTruncatedMessage = errors.New("dns: message truncated")
+
+// Header is the fixed 12-byte DNS message header.
+type Header struct {
+	ID      uint16
+	QR      bool
+	Opcode  uint8
+	AA      bool
+	TC      bool
+	RD      bool
+	RA      bool
+	RCode   uint16
+	QDCount uint16
+	ANCount uint16
+	NSCount uint16
+	ARCount uint16
+}
+
+// Question is a single entry in the question section.
+type Question struct {
+	Name  string
+	Type  uint16
+	Class uint16
+}
+
+// RR is a single resource record in the answer/authority/additional sections.
+type RR struct {
+	Name  string
+	Type  uint16
+	Class uint16
+	TTL   uint32
+	Data  []byte
+}
+
+// Message is a parsed DNS message (RFC 1035 section 4.1).
+type Message struct {
+	Header    Header
+	Questions []Question
+	Answers   []RR
+	Authority []RR
+	Extra     []RR
+}
+
+// ParseMessage decodes a raw DNS message, following name compression
+// pointers in the question and resource record sections.
+func ParseMessage(buf []byte) (*Message, error) {
+	if len(buf) < 12 {
+		return nil, errTruncatedMessage
+	}
+	m := &Message{}
+	h := &m.Header
+	h.ID = binary.BigEndian.Uint16(buf[0:2])
+	flags := binary.BigEndian.Uint16(buf[2:4])
+	h.QR = flags&0x8000 != 0
+	h.Opcode = uint8((flags >> 11) & 0xF)
+	h.AA = flags&0x0400 != 0
+	h.TC = flags&0x0200 != 0
+	h.RD = flags&0x0100 != 0
+	h.RA = flags&0x0080 != 0
+	h.RCode = flags & 0x000F
+	h.QDCount = binary.BigEndian.Uint16(buf[4:6])
+	h.ANCount = binary.BigEndian.Uint16(buf[6:8])
+	h.NSCount = binary.BigEndian.Uint16(buf[8:10])
+	h.ARCount = binary.BigEndian.Uint16(buf[10:12])
+
+	off := 12
+	for i := 0; i < int(h.QDCount); i++ {
+		name, next, err := decodeName(buf, off)
+		if err != nil {
+			return nil, err
+		}
+		if next+4 > len(buf) {
+			return nil, errTruncatedMessage
+		}
+		q := Question{
+			Name:  name,
+			Type:  binary.BigEndian.Uint16(buf[next : next+2]),
+			Class: binary.BigEndian.Uint16(buf[next+2 : next+4]),
+		}
+		m.Questions = append(m.Questions, q)
+		off = next + 4
+	}
+
+	sections := []*[]RR{&m.Answers, &m.Authority, &m.Extra}
+	for i, count := range []int{int(h.ANCount), int(h.NSCount), int(h.ARCount)} {
+		section := sections[i]
+		for i := 0; i < count; i++ {
+			rr, next, err := decodeRR(buf, off)
+			if err != nil {
+				return nil, err
+			}
+			*section = append(*section, *rr)
+			off = next
+		}
+	}
+	return m, nil
+}
+
+// decodeName reads a (possibly compressed) domain name starting at off and
+// returns the dotted-label string plus the offset of the byte after it.
+func decodeName(buf []byte, off int) (string, int, error) {
+	var labels []string
+	origEnd := -1
+	cur := off
+	for jumps := 0; ; jumps++ {
+		if jumps > 64 {
+			return "", 0, errors.New("dns: compression pointer loop")
+		}
+		if cur >= len(buf) {
+			return "", 0, errTruncatedMessage
+		}
+		length := int(buf[cur])
+		if length == 0 {
+			cur++
+			if origEnd == -1 {
+				origEnd = cur
+			}
+			break
+		}
+		if length&0xC0 == 0xC0 {
+			if cur+1 >= len(buf) {
+				return "", 0, errTruncatedMessage
+			}
+			ptr := int(binary.BigEndian.Uint16(buf[cur:cur+2]) & 0x3FFF)
+			if origEnd == -1 {
+				origEnd = cur + 2
+			}
+			cur = ptr
+			continue
+		}
+		cur++
+		if cur+length > len(buf) {
+			return "", 0, errTruncatedMessage
+		}
+		labels = append(labels, string(buf[cur:cur+length]))
+		cur += length
+	}
+	return strings.Join(labels, "."), origEnd, nil
+}
+
+func decodeRR(buf []byte, off int) (*RR, int, error) {
+	name, next, err := decodeName(buf, off)
+	if err != nil {
+		return nil, 0, err
+	}
+	if next+10 > len(buf) {
+		return nil, 0, errTruncatedMessage
+	}
+	rr := &RR{
+		Name:  name,
+		Type:  binary.BigEndian.Uint16(buf[next : next+2]),
+		Class: binary.BigEndian.Uint16(buf[next+2 : next+4]),
+		TTL:   binary.BigEndian.Uint32(buf[next+4 : next+8]),
+	}
+	rdlen := int(binary.BigEndian.Uint16(buf[next+8 : next+10]))
+	start := next + 10
+	if start+rdlen > len(buf) {
+		return nil, 0, errTruncatedMessage
+	}
+	rr.Data = append([]byte(nil), buf[start:start+rdlen]...)
+	return rr, start + rdlen, nil
+}
+
+// encodeName writes a domain name using plain length-prefixed labels; no
+// compression is emitted, which keeps the encoder simple and always valid.
+func encodeName(name string) []byte {
+	name = strings.TrimSuffix(name, ".")
+	var out []byte
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			out = append(out, byte(len(label)))
+			out = append(out, label...)
+		}
+	}
+	out = append(out, 0)
+	return out
+}
+
+// Marshal serializes the message back into wire format.
+func (m *Message) Marshal() ([]byte, error) {
+	var buf []byte
+	var flags uint16
+	if m.Header.QR {
+		flags |= 0x8000
+	}
+	flags |= uint16(m.Header.Opcode&0xF) << 11
+	if m.Header.AA {
+		flags |= 0x0400
+	}
+	if m.Header.TC {
+		flags |= 0x0200
+	}
+	if m.Header.RD {
+		flags |= 0x0100
+	}
+	if m.Header.RA {
+		flags |= 0x0080
+	}
+	flags |= m.Header.RCode & 0x000F
+
+	m.Header.QDCount = uint16(len(m.Questions))
+	m.Header.ANCount = uint16(len(m.Answers))
+	m.Header.NSCount = uint16(len(m.Authority))
+	m.Header.ARCount = uint16(len(m.Extra))
+
+	hdr := make([]byte, 12)
+	binary.BigEndian.PutUint16(hdr[0:2], m.Header.ID)
+	binary.BigEndian.PutUint16(hdr[2:4], flags)
+	binary.BigEndian.PutUint16(hdr[4:6], m.Header.QDCount)
+	binary.BigEndian.PutUint16(hdr[6:8], m.Header.ANCount)
+	binary.BigEndian.PutUint16(hdr[8:10], m.Header.NSCount)
+	binary.BigEndian.PutUint16(hdr[10:12], m.Header.ARCount)
+	buf = append(buf, hdr...)
+
+	for _, q := range m.Questions {
+		buf = append(buf, encodeName(q.Name)...)
+		tail := make([]byte, 4)
+		binary.BigEndian.PutUint16(tail[0:2], q.Type)
+		binary.BigEndian.PutUint16(tail[2:4], q.Class)
+		buf = append(buf, tail...)
+	}
+	for _, section := range [][]RR{m.Answers, m.Authority, m.Extra} {
+		for _, rr := range section {
+			buf = append(buf, encodeName(rr.Name)...)
+			tail := make([]byte, 10)
+			binary.BigEndian.PutUint16(tail[0:2], rr.Type)
+			binary.BigEndian.PutUint16(tail[2:4], rr.Class)
+			binary.BigEndian.PutUint32(tail[4:8], rr.TTL)
+			binary.BigEndian.PutUint16(tail[8:10], uint16(len(rr.Data)))
+			buf = append(buf, tail...)
+			buf = append(buf, rr.Data...)
+		}
+	}
+	return buf, nil
+}
+
+func typeName(t uint16) string {
+	switch t {
+	case TypeA:
+		return "A"
+	case TypeAAAA:
+		return "AAAA"
+	case TypeCNAME:
+		return "CNAME"
+	case TypeMX:
+		return "MX"
+	case TypeNS:
+		return "NS"
+	case TypePTR:
+		return "PTR"
+	case TypeSOA:
+		return "SOA"
+	case TypeTXT:
+		return "TXT"
+	default:
+		return fmt.Sprintf("TYPE%d", t)
+	}
+}
@@ -0,0 +1,90 @@
+package dns
+
+import "testing"
+
+// TestParseMessageSectionCounts guards against filing records into the
+// wrong section when two counts share the same numeric value (e.g. an
+// EDNS0 response with one answer and one OPT record in Extra).
+func TestParseMessageSectionCounts(t *testing.T) {
+	orig := &Message{
+		Header: Header{QR: true},
+		Questions: []Question{
+			{Name: "example.com.", Type: TypeA, Class: ClassIN},
+		},
+		Answers: []RR{
+			{Name: "example.com.", Type: TypeA, Class: ClassIN, TTL: 60, Data: []byte{192, 0, 2, 1}},
+		},
+		Extra: []RR{
+			{Name: ".", Type: 41, Class: 4096, TTL: 0, Data: nil},
+		},
+	}
+
+	buf, err := orig.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	got, err := ParseMessage(buf)
+	if err != nil {
+		t.Fatalf("ParseMessage: %v", err)
+	}
+
+	if len(got.Answers) != 1 {
+		t.Fatalf("Answers = %d records, want 1", len(got.Answers))
+	}
+	if len(got.Authority) != 0 {
+		t.Fatalf("Authority = %d records, want 0", len(got.Authority))
+	}
+	if len(got.Extra) != 1 {
+		t.Fatalf("Extra = %d records, want 1", len(got.Extra))
+	}
+	if got.Answers[0].Type != TypeA {
+		t.Errorf("Answers[0].Type = %d, want %d", got.Answers[0].Type, TypeA)
+	}
+	if got.Extra[0].Type != 41 {
+		t.Errorf("Extra[0].Type = %d, want 41", got.Extra[0].Type)
+	}
+}
+
+// TestParseMessageRoundTrip checks that a realistic multi-section message
+// survives Marshal followed by ParseMessage unchanged.
+func TestParseMessageRoundTrip(t *testing.T) {
+	orig := &Message{
+		Header: Header{ID: 0x1234, QR: true, RD: true, RA: true},
+		Questions: []Question{
+			{Name: "www.example.com.", Type: TypeA, Class: ClassIN},
+		},
+		Answers: []RR{
+			{Name: "www.example.com.", Type: TypeA, Class: ClassIN, TTL: 300, Data: []byte{192, 0, 2, 2}},
+		},
+		Authority: []RR{
+			{Name: "example.com.", Type: TypeNS, Class: ClassIN, TTL: 300, Data: encodeName("ns1.example.com.")},
+		},
+	}
+
+	buf, err := orig.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	got, err := ParseMessage(buf)
+	if err != nil {
+		t.Fatalf("ParseMessage: %v", err)
+	}
+
+	if got.Header.ID != orig.Header.ID {
+		t.Errorf("ID = %#x, want %#x", got.Header.ID, orig.Header.ID)
+	}
+	if len(got.Questions) != 1 || got.Questions[0].Name != "www.example.com" {
+		t.Fatalf("Questions = %+v", got.Questions)
+	}
+	if len(got.Answers) != 1 {
+		t.Fatalf("Answers = %d records, want 1", len(got.Answers))
+	}
+	if len(got.Authority) != 1 {
+		t.Fatalf("Authority = %d records, want 1", len(got.Authority))
+	}
+	if len(got.Extra) != 0 {
+		t.Fatalf("Extra = %d records, want 0", len(got.Extra))
+	}
+}
@@ -0,0 +1,263 @@
+// Package dns implements a small authoritative DNS server that answers
+// queries from a loaded Zone, optionally forwarding to an upstream resolver
+// when it has no local match.
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// whoamiName is the special query name that, instead of consulting the
+// zone, returns the querier's own address as a TXT record - handy as a
+// diagnostic akin to whoami.akamai.net.
+const whoamiName = "whoami"
+
+// Handler answers a single parsed query, returning the message to send back
+// to the client.
+type Handler interface {
+	Handle(query *Message, client net.Addr) *Message
+}
+
+// HandlerFunc adapts a plain function to the Handler interface.
+type HandlerFunc func(query *Message, client net.Addr) *Message
+
+// Handle calls f(query, client).
+func (f HandlerFunc) Handle(query *Message, client net.Addr) *Message {
+	return f(query, client)
+}
+
+// Server is a UDP+TCP DNS responder backed by a Zone, with optional
+// recursion to an upstream resolver for names the zone doesn't cover.
+type Server struct {
+	Addr     string // UDP/TCP bind address, e.g. ":53"
+	Zone     *Zone
+	Upstream string // optional upstream resolver, e.g. "8.8.8.8:53"
+	Handler  Handler
+
+	udpConn net.PacketConn
+	tcpLn   net.Listener
+	wg      sync.WaitGroup
+}
+
+// NewServer builds a Server that answers from zone, forwarding unmatched
+// queries to upstream when set. Its Handler is set to the zone-backed
+// default handler.
+func NewServer(addr string, zone *Zone, upstream string) *Server {
+	s := &Server{Addr: addr, Zone: zone, Upstream: upstream}
+	s.Handler = HandlerFunc(s.defaultHandle)
+	return s
+}
+
+// ListenAndServe starts both the UDP listener (used for all queries) and
+// the TCP listener (used for responses that don't fit in 512 bytes), and
+// blocks serving both until an unrecoverable error occurs.
+func (s *Server) ListenAndServe() error {
+	pc, err := net.ListenPacket("udp", s.Addr)
+	if err != nil {
+		return fmt.Errorf("dns: listen udp: %w", err)
+	}
+	s.udpConn = pc
+
+	ln, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		pc.Close()
+		return fmt.Errorf("dns: listen tcp: %w", err)
+	}
+	s.tcpLn = ln
+
+	errc := make(chan error, 2)
+	go func() { errc <- s.serveUDP() }()
+	go func() { errc <- s.serveTCP() }()
+	return <-errc
+}
+
+// Shutdown closes both listeners and waits for in-flight TCP handlers to
+// finish, returning early with ctx's error if it's cancelled first.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.udpConn != nil {
+		s.udpConn.Close()
+	}
+	if s.tcpLn != nil {
+		s.tcpLn.Close()
+	}
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Server) serveUDP() error {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := s.udpConn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		query, err := ParseMessage(buf[:n])
+		if err != nil {
+			log.Printf("dns: malformed query from %s: %v", addr, err)
+			continue
+		}
+		resp := s.Handler.Handle(query, addr)
+		out, err := resp.Marshal()
+		if err != nil {
+			log.Printf("dns: marshal response: %v", err)
+			continue
+		}
+		if len(out) > 512 {
+			resp.Header.TC = true
+			out, _ = resp.Marshal()
+			out = out[:512]
+		}
+		if _, err := s.udpConn.WriteTo(out, addr); err != nil {
+			log.Printf("dns: write response to %s: %v", addr, err)
+		}
+	}
+}
+
+func (s *Server) serveTCP() error {
+	for {
+		conn, err := s.tcpLn.Accept()
+		if err != nil {
+			return err
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handleTCPConn(conn)
+		}()
+	}
+}
+
+func (s *Server) handleTCPConn(conn net.Conn) {
+	defer conn.Close()
+	var lenBuf [2]byte
+	if _, err := io.ReadFull(conn, lenBuf[:]); err != nil {
+		return
+	}
+	msgLen := binary.BigEndian.Uint16(lenBuf[:])
+	buf := make([]byte, msgLen)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return
+	}
+	query, err := ParseMessage(buf)
+	if err != nil {
+		log.Printf("dns: malformed tcp query from %s: %v", conn.RemoteAddr(), err)
+		return
+	}
+	resp := s.Handler.Handle(query, conn.RemoteAddr())
+	out, err := resp.Marshal()
+	if err != nil {
+		log.Printf("dns: marshal tcp response: %v", err)
+		return
+	}
+	prefix := make([]byte, 2)
+	binary.BigEndian.PutUint16(prefix, uint16(len(out)))
+	conn.Write(prefix)
+	conn.Write(out)
+}
+
+// defaultHandle answers from the zone, falling back to upstream recursion
+// and finally NXDOMAIN/SERVFAIL as appropriate.
+func (s *Server) defaultHandle(query *Message, client net.Addr) *Message {
+	resp := &Message{
+		Header: Header{
+			ID: query.Header.ID,
+			QR: true,
+			RD: query.Header.RD,
+			RA: s.Upstream != "",
+		},
+		Questions: query.Questions,
+	}
+	if len(query.Questions) == 0 {
+		resp.Header.RCode = RCodeFormatError
+		return resp
+	}
+	q := query.Questions[0]
+
+	if q.Type == TypeTXT && q.Name == whoamiName {
+		resp.Header.AA = true
+		resp.Answers = []RR{whoamiRecord(q.Name, client)}
+		return resp
+	}
+
+	if records, ok := s.Zone.Lookup(q.Name, q.Type); ok {
+		resp.Header.AA = true
+		for _, r := range records {
+			data, rtype, err := EncodeRData(r)
+			if err != nil {
+				log.Printf("dns: encode %s %s: %v", r.Name, r.Type, err)
+				continue
+			}
+			ttl := r.TTL
+			resp.Answers = append(resp.Answers, RR{Name: q.Name, Type: rtype, Class: ClassIN, TTL: ttl, Data: data})
+		}
+		return resp
+	}
+	if s.Zone.Has(q.Name) {
+		// Name exists but not for this type: still a positive answer, just empty.
+		resp.Header.AA = true
+		return resp
+	}
+
+	if s.Upstream != "" {
+		if fwd, err := s.forward(query); err == nil {
+			fwd.Header.ID = query.Header.ID
+			return fwd
+		}
+		resp.Header.RCode = RCodeServerFailure
+		return resp
+	}
+
+	resp.Header.RCode = RCodeNameError
+	return resp
+}
+
+// forward relays query to the configured upstream resolver over UDP and
+// returns its parsed response.
+func (s *Server) forward(query *Message) (*Message, error) {
+	raw, err := query.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialTimeout("udp", s.Upstream, 2*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("dns: dial upstream: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Write(raw); err != nil {
+		return nil, fmt.Errorf("dns: write upstream: %w", err)
+	}
+	buf := make([]byte, 512)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, fmt.Errorf("dns: read upstream: %w", err)
+	}
+	return ParseMessage(buf[:n])
+}
+
+func whoamiRecord(name string, client net.Addr) RR {
+	text := client.String()
+	return RR{
+		Name:  name,
+		Type:  TypeTXT,
+		Class: ClassIN,
+		TTL:   0,
+		Data:  append([]byte{byte(len(text))}, text...),
+	}
+}
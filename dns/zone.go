@@ -0,0 +1,235 @@
+package dns
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Record is a single entry loaded from a zone file, keyed by owner name and
+// record type within a Zone.
+type Record struct {
+	Name  string `yaml:"name"`
+	Type  string `yaml:"type"`
+	Value string `yaml:"value"`
+	TTL   uint32 `yaml:"ttl"`
+}
+
+// Zone holds the authoritative records for one or more domains, indexed by
+// lowercase owner name for fast lookup during query handling.
+type Zone struct {
+	Origin  string
+	records map[string][]Record
+}
+
+// NewZone creates an empty zone for the given origin (e.g. "example.com.").
+func NewZone(origin string) *Zone {
+	return &Zone{Origin: origin, records: make(map[string][]Record)}
+}
+
+// Add inserts a record into the zone.
+func (z *Zone) Add(r Record) {
+	key := strings.ToLower(strings.TrimSuffix(r.Name, "."))
+	z.records[key] = append(z.records[key], r)
+}
+
+// Lookup returns the records for name matching the given query type. A qtype
+// of 0 returns every record for the name regardless of type; the wire ANY
+// type (255) is not treated as a wildcard.
+func (z *Zone) Lookup(name string, qtype uint16) ([]Record, bool) {
+	key := strings.ToLower(strings.TrimSuffix(name, "."))
+	all, ok := z.records[key]
+	if !ok {
+		return nil, false
+	}
+	if qtype == 0 {
+		return all, true
+	}
+	var out []Record
+	for _, r := range all {
+		if strings.EqualFold(r.Type, typeName(qtype)) {
+			out = append(out, r)
+		}
+	}
+	return out, len(out) > 0
+}
+
+// Has reports whether any record at all exists for name, used to
+// distinguish NXDOMAIN from a query for a type the name simply lacks.
+func (z *Zone) Has(name string) bool {
+	key := strings.ToLower(strings.TrimSuffix(name, "."))
+	_, ok := z.records[key]
+	return ok
+}
+
+// LoadZoneFile reads a simplified BIND-style zone file. Each non-blank,
+// non-comment line has the form:
+//
+//	name  [ttl]  IN  TYPE  value
+//
+// which covers the common A/AAAA/CNAME/MX/TXT/NS/PTR/SOA cases without
+// pulling in a full master-file parser.
+func LoadZoneFile(path, origin string) (*Zone, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("dns: open zone file: %w", err)
+	}
+	defer f.Close()
+
+	z := NewZone(origin)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		// Strip the "IN" class token; an optional TTL is handled below.
+		var filtered []string
+		for _, f := range fields {
+			if strings.EqualFold(f, "IN") {
+				continue
+			}
+			filtered = append(filtered, f)
+		}
+		if len(filtered) < 3 {
+			return nil, fmt.Errorf("dns: malformed zone line %q", line)
+		}
+		name := qualify(filtered[0], origin)
+		rtype := strings.ToUpper(filtered[1])
+		ttl := uint32(0)
+		valueIdx := 2
+		if n, err := strconv.Atoi(filtered[1]); err == nil {
+			ttl = uint32(n)
+			rtype = strings.ToUpper(filtered[2])
+			valueIdx = 3
+		}
+		if valueIdx >= len(filtered) {
+			return nil, fmt.Errorf("dns: malformed zone line %q", line)
+		}
+		value := strings.Join(filtered[valueIdx:], " ")
+		z.Add(Record{Name: name, Type: rtype, Value: value, TTL: ttl})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return z, nil
+}
+
+// LoadYAMLZone reads a zone described as YAML:
+//
+//	origin: example.com.
+//	records:
+//	  - {name: www, type: A, value: 192.0.2.1, ttl: 300}
+func LoadYAMLZone(path string) (*Zone, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("dns: read yaml zone: %w", err)
+	}
+	var doc struct {
+		Origin  string   `yaml:"origin"`
+		Records []Record `yaml:"records"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("dns: parse yaml zone: %w", err)
+	}
+	z := NewZone(doc.Origin)
+	for _, r := range doc.Records {
+		r.Name = qualify(r.Name, doc.Origin)
+		z.Add(r)
+	}
+	return z, nil
+}
+
+func qualify(name, origin string) string {
+	if name == "@" {
+		return origin
+	}
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "." + strings.TrimSuffix(origin, ".")
+}
+
+// EncodeRData converts a Record's textual value into wire-format RDATA for
+// its type.
+func EncodeRData(r Record) ([]byte, uint16, error) {
+	switch strings.ToUpper(r.Type) {
+	case "A":
+		ip := net.ParseIP(r.Value).To4()
+		if ip == nil {
+			return nil, 0, fmt.Errorf("dns: invalid A value %q", r.Value)
+		}
+		return ip, TypeA, nil
+	case "AAAA":
+		ip := net.ParseIP(r.Value).To16()
+		if ip == nil {
+			return nil, 0, fmt.Errorf("dns: invalid AAAA value %q", r.Value)
+		}
+		return ip, TypeAAAA, nil
+	case "TXT":
+		return encodeTXT(r.Value), TypeTXT, nil
+	case "CNAME":
+		return encodeName(r.Value), TypeCNAME, nil
+	case "NS":
+		return encodeName(r.Value), TypeNS, nil
+	case "PTR":
+		return encodeName(r.Value), TypePTR, nil
+	case "MX":
+		parts := strings.Fields(r.Value)
+		if len(parts) != 2 {
+			return nil, 0, fmt.Errorf("dns: invalid MX value %q, want \"preference host\"", r.Value)
+		}
+		pref, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, 0, fmt.Errorf("dns: invalid MX preference %q", parts[0])
+		}
+		data := []byte{byte(pref >> 8), byte(pref)}
+		data = append(data, encodeName(parts[1])...)
+		return data, TypeMX, nil
+	case "SOA":
+		// mname rname serial refresh retry expire minimum
+		parts := strings.Fields(r.Value)
+		if len(parts) != 7 {
+			return nil, 0, fmt.Errorf("dns: invalid SOA value %q", r.Value)
+		}
+		var data []byte
+		data = append(data, encodeName(parts[0])...)
+		data = append(data, encodeName(parts[1])...)
+		for _, p := range parts[2:] {
+			n, err := strconv.ParseUint(p, 10, 32)
+			if err != nil {
+				return nil, 0, fmt.Errorf("dns: invalid SOA field %q", p)
+			}
+			data = append(data, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+		}
+		return data, TypeSOA, nil
+	default:
+		return nil, 0, fmt.Errorf("dns: unsupported record type %q", r.Type)
+	}
+}
+
+// txtChunkMax is the largest a single TXT character-string may be: the
+// length prefix is one byte, per RFC 1035 section 3.3.14.
+const txtChunkMax = 255
+
+// encodeTXT splits value into one or more length-prefixed character-strings
+// so values longer than 255 bytes (common for SPF/DKIM records) round-trip
+// correctly instead of wrapping the length prefix and desyncing the rest of
+// the message.
+func encodeTXT(value string) []byte {
+	var out []byte
+	for len(value) > txtChunkMax {
+		out = append(out, byte(txtChunkMax))
+		out = append(out, value[:txtChunkMax]...)
+		value = value[txtChunkMax:]
+	}
+	out = append(out, byte(len(value)))
+	out = append(out, value...)
+	return out
+}
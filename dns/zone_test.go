@@ -0,0 +1,79 @@
+package dns
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestZoneLookup(t *testing.T) {
+	z := NewZone("example.com.")
+	z.Add(Record{Name: "www.example.com.", Type: "A", Value: "192.0.2.1", TTL: 300})
+	z.Add(Record{Name: "www.example.com.", Type: "AAAA", Value: "2001:db8::1", TTL: 300})
+
+	recs, ok := z.Lookup("www.example.com.", TypeA)
+	if !ok || len(recs) != 1 || recs[0].Value != "192.0.2.1" {
+		t.Fatalf("Lookup(A) = %+v, %v", recs, ok)
+	}
+
+	recs, ok = z.Lookup("WWW.EXAMPLE.COM.", 0)
+	if !ok || len(recs) != 2 {
+		t.Fatalf("Lookup(ANY, mixed case) = %+v, %v, want 2 records", recs, ok)
+	}
+
+	if _, ok := z.Lookup("www.example.com.", TypeMX); ok {
+		t.Fatalf("Lookup(MX) should miss for a name with no MX record")
+	}
+
+	if !z.Has("www.example.com.") {
+		t.Fatalf("Has(www.example.com.) = false, want true")
+	}
+	if z.Has("missing.example.com.") {
+		t.Fatalf("Has(missing.example.com.) = true, want false")
+	}
+}
+
+func TestEncodeRDataTXTLongValue(t *testing.T) {
+	// A 300-byte value must split into a 255-byte character-string
+	// followed by a 45-byte one, not wrap a single length prefix mod 256.
+	value := strings.Repeat("a", 300)
+	data, rtype, err := EncodeRData(Record{Type: "TXT", Value: value})
+	if err != nil {
+		t.Fatalf("EncodeRData: %v", err)
+	}
+	if rtype != TypeTXT {
+		t.Fatalf("rtype = %d, want %d", rtype, TypeTXT)
+	}
+
+	if len(data) != 1+255+1+45 {
+		t.Fatalf("len(data) = %d, want %d", len(data), 1+255+1+45)
+	}
+	if data[0] != 255 {
+		t.Fatalf("first chunk length = %d, want 255", data[0])
+	}
+	if got := data[1+255]; got != 45 {
+		t.Fatalf("second chunk length = %d, want 45", got)
+	}
+
+	var decoded string
+	off := 0
+	for off < len(data) {
+		n := int(data[off])
+		off++
+		decoded += string(data[off : off+n])
+		off += n
+	}
+	if decoded != value {
+		t.Fatalf("decoded value length = %d, want %d", len(decoded), len(value))
+	}
+}
+
+func TestEncodeRDataTXTShortValue(t *testing.T) {
+	data, _, err := EncodeRData(Record{Type: "TXT", Value: "v=spf1 -all"})
+	if err != nil {
+		t.Fatalf("EncodeRData: %v", err)
+	}
+	want := append([]byte{byte(len("v=spf1 -all"))}, "v=spf1 -all"...)
+	if string(data) != string(want) {
+		t.Fatalf("data = %q, want %q", data, want)
+	}
+}
@@ -0,0 +1,195 @@
+// Package icmp implements an ICMP echo (ping) responder using
+// golang.org/x/net/icmp, supporting both the privileged raw-socket mode and
+// the unprivileged datagram mode.
+package icmp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// Network selects the socket mode used to listen for echo requests.
+type Network string
+
+const (
+	// NetworkPrivileged uses a raw ICMP socket; requires CAP_NET_RAW (or root).
+	NetworkPrivileged Network = "ip4:icmp"
+	// NetworkUnprivileged uses a UDP datagram socket; works without elevated
+	// privileges when net.ipv4.ping_group_range permits the process's group.
+	NetworkUnprivileged Network = "udp4"
+	// NetworkUnprivileged6 is the IPv6 counterpart of NetworkUnprivileged.
+	NetworkUnprivileged6 Network = "udp6"
+	// NetworkPrivileged6 is the IPv6 counterpart of NetworkPrivileged.
+	NetworkPrivileged6 Network = "ip6:ipv6-icmp"
+)
+
+// clientStats tracks echo diagnostics for a single peer address. The server
+// never observes the client's send timestamp, so it cannot compute a true
+// round-trip time; LastInterval is the gap between two consecutive echo
+// requests from the same peer instead.
+type clientStats struct {
+	Addr         string        `json:"addr"`
+	EchoCount    uint64        `json:"echo_count"`
+	LastInterval time.Duration `json:"last_interval_ns"`
+	LastSeen     time.Time     `json:"last_seen"`
+	LastSeq      int           `json:"last_seq"`
+	PacketsLost  uint64        `json:"packets_lost"`
+}
+
+// EchoServer listens for ICMP echo requests on one or more networks and
+// replies with a properly recomputed EchoReply, tracking per-client
+// inter-arrival and loss statistics exposed over HTTP.
+type EchoServer struct {
+	// Listeners maps each network mode (e.g. NetworkUnprivileged) to the
+	// bind address to listen on (e.g. "0.0.0.0" or "::").
+	Listeners map[Network]string
+
+	mu    sync.Mutex
+	stats map[string]*clientStats
+
+	conns []net.PacketConn
+}
+
+// NewEchoServer builds an EchoServer for the given network->address pairs.
+func NewEchoServer(listeners map[Network]string) *EchoServer {
+	return &EchoServer{
+		Listeners: listeners,
+		stats:     make(map[string]*clientStats),
+	}
+}
+
+// ListenAndServe opens every configured listener and serves echo requests
+// until any of them returns an error.
+func (s *EchoServer) ListenAndServe() error {
+	errc := make(chan error, len(s.Listeners))
+	for network, addr := range s.Listeners {
+		conn, err := icmp.ListenPacket(string(network), addr)
+		if err != nil {
+			return fmt.Errorf("icmp: listen %s %s: %w", network, addr, err)
+		}
+		s.conns = append(s.conns, conn)
+		go func(network Network, conn *icmp.PacketConn) {
+			errc <- s.serve(network, conn)
+		}(network, conn)
+	}
+	return <-errc
+}
+
+// Shutdown closes every listener, which unblocks each serve loop's
+// ReadFrom call immediately since packets are handled synchronously.
+func (s *EchoServer) Shutdown(ctx context.Context) error {
+	for _, c := range s.conns {
+		c.Close()
+	}
+	return nil
+}
+
+func (s *EchoServer) serve(network Network, conn *icmp.PacketConn) error {
+	proto := ipv4.ICMPTypeEcho.Protocol()
+	isV6 := network == NetworkUnprivileged6 || network == NetworkPrivileged6
+	if isV6 {
+		proto = ipv6.ICMPTypeEchoRequest.Protocol()
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		msg, err := icmp.ParseMessage(proto, buf[:n])
+		if err != nil {
+			log.Printf("icmp: parse message from %s: %v", peer, err)
+			continue
+		}
+		echo, ok := msg.Body.(*icmp.Echo)
+		if msg.Type != ipv4.ICMPTypeEcho && msg.Type != ipv6.ICMPTypeEchoRequest {
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		s.recordEcho(peer, echo.Seq)
+
+		var replyType icmp.Type = ipv4.ICMPTypeEchoReply
+		if isV6 {
+			replyType = ipv6.ICMPTypeEchoReply
+		}
+		reply := icmp.Message{
+			Type: replyType,
+			Code: 0,
+			Body: &icmp.Echo{
+				ID:   echo.ID,
+				Seq:  echo.Seq,
+				Data: echo.Data,
+			},
+		}
+		// Checksum is computed by Marshal when the pseudo-header argument is
+		// nil, which is correct for both the raw ip4:icmp and udp4 ICMP
+		// sockets used here.
+		out, err := reply.Marshal(nil)
+		if err != nil {
+			log.Printf("icmp: marshal reply to %s: %v", peer, err)
+			continue
+		}
+		if _, err := conn.WriteTo(out, peer); err != nil {
+			log.Printf("icmp: write reply to %s: %v", peer, err)
+		}
+	}
+}
+
+func (s *EchoServer) recordEcho(peer net.Addr, seq int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := peer.String()
+	st, ok := s.stats[key]
+	if !ok {
+		st = &clientStats{Addr: key}
+		s.stats[key] = st
+	}
+	switch {
+	case st.EchoCount == 0:
+		// First packet from this peer: nothing to compare against yet.
+	case seq > st.LastSeq+1:
+		st.PacketsLost += uint64(seq - st.LastSeq - 1)
+	case seq <= st.LastSeq:
+		// Sequence repeated or went backward: a new ping session reusing this peer
+		// address (or the client's process restarted), not real loss.
+		st.PacketsLost = 0
+	}
+	st.EchoCount++
+	st.LastSeq = seq
+	if !st.LastSeen.IsZero() {
+		st.LastInterval = time.Since(st.LastSeen)
+	}
+	st.LastSeen = time.Now()
+}
+
+// MetricsHandler returns an http.HandlerFunc that serves per-client echo
+// count, inter-arrival interval and packet-loss statistics as JSON, suitable
+// for mounting at /metrics. No round-trip time is reported.
+func (s *EchoServer) MetricsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		out := make([]*clientStats, 0, len(s.stats))
+		for _, st := range s.stats {
+			out = append(out, st)
+		}
+		s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package icmp
+
+import (
+	"net"
+	"testing"
+)
+
+func TestRecordEchoSequenceGoesBackward(t *testing.T) {
+	s := NewEchoServer(nil)
+	peer := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1}
+
+	s.recordEcho(peer, 50)
+	st := s.stats[peer.String()]
+	if st.PacketsLost != 0 {
+		t.Fatalf("PacketsLost after first packet = %d, want 0", st.PacketsLost)
+	}
+
+	// Client process restarted and reset its sequence to 0: this must not
+	// underflow PacketsLost into a huge bogus value.
+	s.recordEcho(peer, 0)
+	if st.PacketsLost != 0 {
+		t.Fatalf("PacketsLost after sequence reset = %d, want 0", st.PacketsLost)
+	}
+	if st.LastSeq != 0 {
+		t.Fatalf("LastSeq = %d, want 0", st.LastSeq)
+	}
+}
+
+func TestRecordEchoCountsGaps(t *testing.T) {
+	s := NewEchoServer(nil)
+	peer := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1}
+
+	s.recordEcho(peer, 1)
+	s.recordEcho(peer, 4)
+	st := s.stats[peer.String()]
+	if st.PacketsLost != 2 {
+		t.Fatalf("PacketsLost = %d, want 2", st.PacketsLost)
+	}
+}
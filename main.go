@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/scatter222/simple-server/config"
+)
+
+// shutdownGrace bounds how long Shutdown gets to drain in-flight work
+// before main gives up and exits non-zero.
+const shutdownGrace = 10 * time.Second
+
+func main() {
+	configPath := flag.String("config", "", "path to a YAML or TOML config file")
+	configCheck := flag.Bool("config.check", false, "validate the config and exit")
+	httpAddr := flag.String("http.addr", "", "override http.addr from the config")
+	dnsAddr := flag.String("dns.addr", "", "override dns.addr from the config")
+	icmpMode := flag.String("icmp.mode", "", "override icmp.mode from the config (privileged|unprivileged)")
+	smtpAddr := flag.String("smtp.addr", "", "override smtp.addr from the config")
+	flag.Parse()
+
+	cfg := config.Default()
+	if *configPath != "" {
+		loaded, err := config.Load(*configPath)
+		if err != nil {
+			log.Fatalf("Failed to load config: %v", err)
+		}
+		cfg = loaded
+	}
+	if *httpAddr != "" {
+		cfg.HTTP.Addr = *httpAddr
+	}
+	if *dnsAddr != "" {
+		cfg.DNS.Addr = *dnsAddr
+	}
+	if *icmpMode != "" {
+		cfg.ICMP.Mode = config.ICMPMode(*icmpMode)
+	}
+	if *smtpAddr != "" {
+		cfg.SMTP.Addr = *smtpAddr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *configCheck {
+		fmt.Println("config OK")
+		return
+	}
+
+	var services []Service
+	if cfg.HTTP.Enabled {
+		services = append(services, newHTTPServers(cfg.HTTP)...)
+	}
+	if cfg.DNS.Enabled {
+		services = append(services, newDNSServer(cfg.DNS))
+	}
+	if cfg.ICMP.Enabled {
+		services = append(services, newICMPServer(cfg.ICMP))
+	}
+	if cfg.SMTP.Enabled {
+		services = append(services, newSMTPServer(cfg.SMTP))
+	}
+
+	var wg sync.WaitGroup
+	runErrs := make(chan error, len(services))
+	for _, svc := range services {
+		wg.Add(1)
+		go func(svc Service) {
+			defer wg.Done()
+			log.Printf("Starting %s service\n", svc.Name())
+			if err := svc.Start(context.Background()); err != nil {
+				runErrs <- err
+			}
+		}(svc)
+	}
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+
+	for {
+		select {
+		case sig := <-sigc:
+			if sig == syscall.SIGHUP {
+				reload(services)
+				continue
+			}
+			log.Printf("Received %s, shutting down\n", sig)
+			shutdown(services)
+			wg.Wait()
+			return
+		case err := <-runErrs:
+			log.Printf("Service failed: %v\n", err)
+			shutdown(services)
+			wg.Wait()
+			os.Exit(1)
+		}
+	}
+}
+
+// shutdown stops every service concurrently, giving each up to
+// shutdownGrace to finish before main exits anyway.
+func shutdown(services []Service) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownGrace)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, svc := range services {
+		wg.Add(1)
+		go func(svc Service) {
+			defer wg.Done()
+			if err := svc.Shutdown(ctx); err != nil {
+				log.Printf("%s service failed to stop cleanly: %v\n", svc.Name(), err)
+			}
+		}(svc)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Println("Shutdown grace period exceeded; exiting anyway")
+		os.Exit(1)
+	}
+}
+
+// reload rotates the log output and re-reads configuration (e.g. the DNS
+// zone file) for every service that supports it, without restarting any
+// listener.
+func reload(services []Service) {
+	log.Println("Received SIGHUP: reloading")
+	rotateLogFile()
+	for _, svc := range services {
+		if r, ok := svc.(Reloadable); ok {
+			if err := r.Reload(); err != nil {
+				log.Printf("%s service failed to reload: %v\n", svc.Name(), err)
+			}
+		}
+	}
+}
+
+// currentLogFile holds the handle log output is currently writing to, so a
+// later rotation can close it instead of leaking it. Only ever touched from
+// the main select loop, so it needs no lock.
+var currentLogFile *os.File
+
+// rotateLogFile reopens the log file named by LOG_FILE, picking up a
+// renamed/rotated file on disk without losing any subsequent writes. It is
+// a no-op when LOG_FILE isn't set, in which case logs stay on stderr.
+func rotateLogFile() {
+	path := os.Getenv("LOG_FILE")
+	if path == "" {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		log.Printf("Failed to rotate log file: %v\n", err)
+		return
+	}
+	log.SetOutput(f)
+	if currentLogFile != nil {
+		currentLogFile.Close()
+	}
+	currentLogFile = f
+}
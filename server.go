@@ -1,18 +1,25 @@
 package main
 
 import (
-	"fmt"
+	"crypto/tls"
 	"io"
 	"log"
-	"net"
 	"net/http"
 	"os"
-	"strings"
-	"time"
+	"path/filepath"
+
+	"github.com/scatter222/simple-server/config"
+	"github.com/scatter222/simple-server/dns"
+	"github.com/scatter222/simple-server/icmp"
+	"github.com/scatter222/simple-server/smtp"
 )
 
-// HTTP server
-func startHTTPServer() {
+// newHTTPServers builds the HTTP service(s). Routes registered here (and by
+// the ICMP/SMTP services' /metrics and /mailbox handlers) are served on
+// http.DefaultServeMux, shared by every returned *http.Server. A plain
+// listener is always started on cfg.Addr; if cfg.TLSAddr is also set, a
+// second, independent HTTPS listener is started alongside it.
+func newHTTPServers(cfg config.HTTPConfig) []Service {
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		log.Printf("HTTP Request: %s %s from %s\n", r.Method, r.URL, r.RemoteAddr)
 		_, _ = w.Write([]byte("HTTP server is working!\n"))
@@ -22,93 +29,100 @@ func startHTTPServer() {
 			http.Error(w, "Invalid method", http.StatusMethodNotAllowed)
 			return
 		}
-		file, _, err := r.FormFile("file")
+		file, header, err := r.FormFile("file")
 		if err != nil {
 			http.Error(w, "Failed to get file", http.StatusBadRequest)
 			return
 		}
 		defer file.Close()
-		log.Println("File uploaded successfully")
-		_, _ = io.Copy(os.Stdout, file) // Print file content to console
+
+		if cfg.UploadDir == "" {
+			log.Println("File uploaded successfully")
+			_, _ = io.Copy(os.Stdout, file) // Print file content to console
+			w.Write([]byte("File upload successful\n"))
+			return
+		}
+
+		dst, err := os.Create(filepath.Join(cfg.UploadDir, filepath.Base(header.Filename)))
+		if err != nil {
+			http.Error(w, "Failed to store file", http.StatusInternalServerError)
+			return
+		}
+		defer dst.Close()
+		if _, err := io.Copy(dst, file); err != nil {
+			http.Error(w, "Failed to store file", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("File uploaded successfully to %s\n", dst.Name())
 		w.Write([]byte("File upload successful\n"))
 	})
-	log.Println("Starting HTTP server on port 8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
-}
 
-// DNS server
-func startDNSServer() {
-	pc, err := net.ListenPacket("udp", ":53")
-	if err != nil {
-		log.Fatalf("Failed to start DNS server: %v", err)
-	}
-	defer pc.Close()
+	services := []Service{&httpService{srv: &http.Server{Addr: cfg.Addr}}}
 
-	log.Println("DNS server is listening on port 53")
-	buf := make([]byte, 512)
-	for {
-		n, addr, err := pc.ReadFrom(buf)
+	if cfg.TLSAddr != "" {
+		cert, err := config.LoadOrGenerateCert(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.Hostname)
 		if err != nil {
-			log.Printf("Error reading from DNS: %v\n", err)
-			continue
+			log.Fatalf("Failed to load HTTPS certificate: %v", err)
 		}
-		log.Printf("DNS Request from %s: %x\n", addr, buf[:n])
-		pc.WriteTo(buf[:n], addr) // Echo the request back
+		tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+		tlsSrv := &http.Server{Addr: cfg.TLSAddr, TLSConfig: tlsConfig}
+		if !cfg.HTTP2 {
+			// A non-nil TLSNextProto without an "h2" entry keeps net/http
+			// from enabling HTTP/2 on this listener.
+			tlsSrv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		}
+		services = append(services, &httpService{srv: tlsSrv, tls: true})
 	}
+	return services
+}
+
+// newDNSServer builds the DNS service from cfg's zone file, if any.
+func newDNSServer(cfg config.DNSConfig) *dnsService {
+	zone := loadDNSZone(cfg.ZoneFile)
+	srv := dns.NewServer(cfg.Addr, zone, cfg.Upstream)
+	return &dnsService{srv: srv, zonePath: cfg.ZoneFile}
 }
 
-// ICMP server (ping)
-func startICMPServer() {
-	conn, err := net.ListenPacket("ip4:icmp", "0.0.0.0")
+func loadDNSZone(zonePath string) *dns.Zone {
+	zone := dns.NewZone("example.com.")
+	if zonePath == "" {
+		return zone
+	}
+	loaded, err := dns.LoadZoneFile(zonePath, zone.Origin)
 	if err != nil {
-		log.Fatalf("Failed to start ICMP server: %v", err)
+		log.Fatalf("Failed to load DNS zone file: %v", err)
 	}
-	defer conn.Close()
+	return loaded
+}
 
-	log.Println("ICMP server is listening")
-	buf := make([]byte, 1024)
-	for {
-		n, addr, err := conn.ReadFrom(buf)
-		if err != nil {
-			log.Printf("Error reading ICMP: %v\n", err)
-			continue
-		}
-		log.Printf("ICMP Request from %s\n", addr)
-		_, _ = conn.WriteTo(buf[:n], addr)
+// newICMPServer builds the ICMP echo service and mounts its /metrics
+// endpoint on the shared HTTP mux.
+func newICMPServer(cfg config.ICMPConfig) *icmpService {
+	mode := icmp.NetworkUnprivileged
+	if cfg.Mode == config.ICMPModePrivileged {
+		mode = icmp.NetworkPrivileged
 	}
+	srv := icmp.NewEchoServer(map[icmp.Network]string{mode: cfg.Bind})
+	http.HandleFunc("/metrics", srv.MetricsHandler())
+	return &icmpService{srv: srv}
 }
 
-// SMTP server
-func startSMTPServer() {
-	ln, err := net.Listen("tcp", ":25")
+// newSMTPServer builds the SMTP service and mounts its /mailbox endpoints
+// on the shared HTTP mux.
+func newSMTPServer(cfg config.SMTPConfig) *smtpService {
+	mailbox, err := smtp.NewMailbox(500, cfg.MaildirPath)
 	if err != nil {
-		log.Fatalf("Failed to start SMTP server: %v", err)
+		log.Fatalf("Failed to create SMTP mailbox: %v", err)
 	}
-	defer ln.Close()
+	http.Handle("/mailbox", mailbox.Handler("/mailbox"))
+	http.Handle("/mailbox/", mailbox.Handler("/mailbox"))
 
-	log.Println("SMTP server is listening on port 25")
-	for {
-		conn, err := ln.Accept()
-		if err != nil {
-			log.Printf("Failed to accept SMTP connection: %v\n", err)
-			continue
-		}
-		go func(c net.Conn) {
-			defer c.Close()
-			log.Printf("SMTP Connection from %s\n", c.RemoteAddr())
-			c.Write([]byte("220 Simple SMTP Test Server\n"))
-			time.Sleep(10 * time.Second) // Simulate delay
-		}(conn)
+	cert, err := config.LoadOrGenerateCert(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.Hostname)
+	if err != nil {
+		log.Fatalf("Failed to load SMTP TLS certificate: %v", err)
 	}
-}
-
-// Start all services
-func main() {
-	go startHTTPServer()
-	go startDNSServer()
-	go startICMPServer()
-	go startSMTPServer()
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
 
-	// Keep the main thread alive
-	select {}
+	srv := smtp.NewServer(cfg.Hostname, mailbox, tlsConfig)
+	return &smtpService{srv: srv, addr: cfg.Addr, submissionAddr: cfg.SubmissionAddr}
 }
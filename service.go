@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/scatter222/simple-server/dns"
+	"github.com/scatter222/simple-server/icmp"
+	"github.com/scatter222/simple-server/smtp"
+)
+
+// Service is implemented by every server managed by main's lifecycle: it
+// must be startable and stoppable on demand rather than blocking forever
+// and calling log.Fatal on failure.
+type Service interface {
+	// Name identifies the service in lifecycle log messages.
+	Name() string
+	// Start runs the service until Shutdown is called or an unrecoverable
+	// error occurs. It does not return until the service has stopped. The
+	// implementations here do not stop when ctx is cancelled.
+	Start(ctx context.Context) error
+	// Shutdown stops the service, waiting for in-flight work to finish or
+	// ctx to expire, whichever comes first.
+	Shutdown(ctx context.Context) error
+}
+
+// httpService adapts an *http.Server to the Service interface.
+type httpService struct {
+	srv *http.Server
+	tls bool
+}
+
+func (s *httpService) Name() string { return "http" }
+
+func (s *httpService) Start(ctx context.Context) error {
+	var err error
+	if s.tls {
+		err = s.srv.ListenAndServeTLS("", "")
+	} else {
+		err = s.srv.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func (s *httpService) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
+
+// Reloadable is implemented by services that can re-read their
+// configuration in place, in response to SIGHUP, without tearing down
+// their listeners.
+type Reloadable interface {
+	Reload() error
+}
+
+// dnsService adapts a *dns.Server to the Service interface.
+type dnsService struct {
+	srv      *dns.Server
+	zonePath string
+}
+
+func (s *dnsService) Name() string {
+	return "dns"
+}
+
+func (s *dnsService) Start(ctx context.Context) error {
+	return s.srv.ListenAndServe()
+}
+
+func (s *dnsService) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
+
+// Reload re-reads the zone file and swaps it in, leaving the UDP/TCP
+// listeners untouched.
+func (s *dnsService) Reload() error {
+	if s.zonePath == "" {
+		return nil
+	}
+	zone, err := dns.LoadZoneFile(s.zonePath, s.srv.Zone.Origin)
+	if err != nil {
+		return err
+	}
+	s.srv.Zone = zone
+	return nil
+}
+
+// icmpService adapts an *icmp.EchoServer to the Service interface.
+type icmpService struct {
+	srv *icmp.EchoServer
+}
+
+func (s *icmpService) Name() string {
+	return "icmp"
+}
+
+func (s *icmpService) Start(ctx context.Context) error {
+	return s.srv.ListenAndServe()
+}
+
+func (s *icmpService) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
+
+// smtpService adapts an *smtp.Server to the Service interface, running the
+// plain and submission listeners concurrently.
+type smtpService struct {
+	srv            *smtp.Server
+	addr           string
+	submissionAddr string
+}
+
+func (s *smtpService) Name() string { return "smtp" }
+
+func (s *smtpService) Start(ctx context.Context) error {
+	errc := make(chan error, 2)
+	go func() { errc <- s.srv.ListenPlain(s.addr) }()
+	go func() {
+		if err := s.srv.ListenSubmission(s.submissionAddr); err != nil {
+			log.Printf("smtp: submission listener stopped: %v", err)
+		}
+	}()
+	return <-errc
+}
+
+func (s *smtpService) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
@@ -0,0 +1,106 @@
+package smtp
+
+import (
+	"bytes"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Message is a single received mail, kept in the in-memory ring buffer and,
+// when the mailbox has a maildir, on disk in maildir format.
+type Message struct {
+	ID        string    `json:"id"`
+	From      string    `json:"from"`
+	To        []string  `json:"to"`
+	Subject   string    `json:"subject"`
+	Received  time.Time `json:"received"`
+	Raw       []byte    `json:"-"`
+	MaildirID string    `json:"-"`
+}
+
+// Mailbox is a MailHog-style test mail sink: every delivered message is
+// kept in a fixed-size in-memory ring buffer for quick browsing and, when a
+// maildir directory is set, persisted to it on disk.
+type Mailbox struct {
+	mu       sync.Mutex
+	messages []*Message
+	cap      int
+	dir      string // maildir root; empty disables disk persistence
+}
+
+// NewMailbox creates a Mailbox holding at most capacity messages in memory.
+// If dir is non-empty, every message is also written to dir in maildir
+// format (new/cur/tmp subdirectories).
+func NewMailbox(capacity int, dir string) (*Mailbox, error) {
+	mb := &Mailbox{cap: capacity, dir: dir}
+	if dir != "" {
+		for _, sub := range []string{"new", "cur", "tmp"} {
+			if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+				return nil, fmt.Errorf("smtp: create maildir %s: %w", sub, err)
+			}
+		}
+	}
+	return mb, nil
+}
+
+// Deliver stores raw as a new message from from to recipients, parsing the
+// subject header with net/mail for display purposes.
+func (mb *Mailbox) Deliver(from string, to []string, raw []byte) (*Message, error) {
+	subject := ""
+	if parsed, err := mail.ReadMessage(bytes.NewReader(raw)); err == nil {
+		subject = parsed.Header.Get("Subject")
+	}
+
+	id := fmt.Sprintf("%d.%d", time.Now().UnixNano(), len(raw))
+	msg := &Message{
+		ID:       id,
+		From:     from,
+		To:       to,
+		Subject:  subject,
+		Received: time.Now(),
+		Raw:      raw,
+	}
+
+	if mb.dir != "" {
+		name := fmt.Sprintf("%s.%d.simple-server", id, os.Getpid())
+		path := filepath.Join(mb.dir, "new", name)
+		if err := os.WriteFile(path, raw, 0o644); err != nil {
+			return nil, fmt.Errorf("smtp: write maildir message: %w", err)
+		}
+		msg.MaildirID = name
+	}
+
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.messages = append(mb.messages, msg)
+	if len(mb.messages) > mb.cap {
+		mb.messages = mb.messages[len(mb.messages)-mb.cap:]
+	}
+	return msg, nil
+}
+
+// List returns the messages currently held in the ring buffer, most recent
+// last.
+func (mb *Mailbox) List() []*Message {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	out := make([]*Message, len(mb.messages))
+	copy(out, mb.messages)
+	return out
+}
+
+// Get returns the message with the given ID, if still in the ring buffer.
+func (mb *Mailbox) Get(id string) (*Message, bool) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	for _, m := range mb.messages {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return nil, false
+}
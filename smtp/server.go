@@ -0,0 +1,95 @@
+// Package smtp implements a MailHog-style test mail sink: a real RFC 5321
+// command state machine that stores received messages in memory and on
+// disk, browsable through a small HTTP mailbox UI.
+package smtp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+)
+
+// Server holds the configuration and shared state for the SMTP listeners.
+type Server struct {
+	Hostname       string
+	Banner         string
+	Mailbox        *Mailbox
+	TLSConfig      *tls.Config
+	MaxMessageSize int
+
+	mu        sync.Mutex
+	listeners []net.Listener
+	wg        sync.WaitGroup
+}
+
+// NewServer creates a Server backed by mailbox. tlsConfig is used for
+// STARTTLS; pass nil to disable STARTTLS advertisement entirely.
+func NewServer(hostname string, mailbox *Mailbox, tlsConfig *tls.Config) *Server {
+	return &Server{
+		Hostname:       hostname,
+		Mailbox:        mailbox,
+		TLSConfig:      tlsConfig,
+		MaxMessageSize: 25 * 1024 * 1024,
+	}
+}
+
+// ListenPlain serves plain-text SMTP (with optional STARTTLS upgrade) on
+// addr, typically ":25". It blocks until the listener fails.
+func (s *Server) ListenPlain(addr string) error {
+	return s.listen(addr, false)
+}
+
+// ListenSubmission serves authenticated mail submission on addr, typically
+// ":587", rejecting MAIL FROM until the client has authenticated.
+func (s *Server) ListenSubmission(addr string) error {
+	return s.listen(addr, true)
+}
+
+func (s *Server) listen(addr string, requireAuth bool) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("smtp: listen %s: %w", addr, err)
+	}
+	s.mu.Lock()
+	s.listeners = append(s.listeners, ln)
+	s.mu.Unlock()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		s.wg.Add(1)
+		go func(c net.Conn) {
+			defer s.wg.Done()
+			log.Printf("SMTP connection from %s\n", c.RemoteAddr())
+			newSession(s, c, requireAuth).serve()
+		}(conn)
+	}
+}
+
+// Shutdown closes every listener opened by Listen* and waits for in-flight
+// sessions to finish, returning early with ctx's error if it's cancelled
+// first.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	listeners := s.listeners
+	s.mu.Unlock()
+	for _, ln := range listeners {
+		ln.Close()
+	}
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
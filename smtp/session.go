@@ -0,0 +1,316 @@
+package smtp
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// state is the session's position in the RFC 5321 command sequence.
+type state int
+
+const (
+	stateGreeting state = iota
+	stateReady          // after HELO/EHLO
+	stateMail           // after MAIL FROM
+	stateRcpt           // after at least one RCPT TO
+	stateData           // collecting DATA
+)
+
+// session handles one client connection end-to-end, implementing the
+// command state machine described in RFC 5321.
+type session struct {
+	srv  *Server
+	conn net.Conn
+	tp   *textproto.Conn
+	tls  bool
+
+	state       state
+	heloName    string
+	mailFrom    string
+	rcptTo      []string
+	authedUser  string
+	requireAuth bool
+}
+
+func newSession(srv *Server, conn net.Conn, requireAuth bool) *session {
+	return &session{
+		srv:         srv,
+		conn:        conn,
+		tp:          textproto.NewConn(conn),
+		requireAuth: requireAuth,
+	}
+}
+
+func (s *session) serve() {
+	defer s.conn.Close()
+	s.tp.PrintfLine("220 %s ESMTP simple-server ready", s.srv.Hostname)
+
+	for {
+		line, err := s.tp.ReadLine()
+		if err != nil {
+			return
+		}
+		if !s.handleLine(line) {
+			return
+		}
+	}
+}
+
+// handleLine dispatches a single command line and returns false when the
+// session should be closed (QUIT or a fatal I/O condition).
+func (s *session) handleLine(line string) bool {
+	verb, rest := splitCommand(line)
+	switch strings.ToUpper(verb) {
+	case "HELO":
+		s.heloName = rest
+		s.state = stateReady
+		s.tp.PrintfLine("250 %s greets %s", s.srv.Hostname, rest)
+	case "EHLO":
+		s.heloName = rest
+		s.state = stateReady
+		s.writeEHLOResponse(rest)
+	case "MAIL":
+		s.handleMail(rest)
+	case "RCPT":
+		s.handleRcpt(rest)
+	case "DATA":
+		s.handleData()
+	case "RSET":
+		s.resetTransaction()
+		s.tp.PrintfLine("250 OK")
+	case "NOOP":
+		s.tp.PrintfLine("250 OK")
+	case "VRFY":
+		s.tp.PrintfLine("252 Cannot VRFY user, but will accept message and attempt delivery")
+	case "STARTTLS":
+		s.handleStartTLS()
+	case "AUTH":
+		s.handleAuth(rest)
+	case "QUIT":
+		s.tp.PrintfLine("221 %s closing connection", s.srv.Hostname)
+		return false
+	default:
+		s.tp.PrintfLine("500 Command not recognized")
+	}
+	return true
+}
+
+func (s *session) writeEHLOResponse(rest string) {
+	lines := []string{fmt.Sprintf("250-%s greets %s", s.srv.Hostname, rest)}
+	if !s.tls && s.srv.TLSConfig != nil {
+		lines = append(lines, "250-STARTTLS")
+	}
+	lines = append(lines, "250-AUTH PLAIN LOGIN")
+	lines = append(lines, fmt.Sprintf("250-SIZE %d", s.srv.MaxMessageSize))
+	lines = append(lines, "250 8BITMIME")
+	for _, l := range lines {
+		s.tp.PrintfLine("%s", l)
+	}
+}
+
+func (s *session) handleMail(rest string) {
+	if s.requireAuth && s.authedUser == "" {
+		s.tp.PrintfLine("530 Authentication required")
+		return
+	}
+	addr, ok := parseMailParam(rest, "FROM:")
+	if !ok {
+		s.tp.PrintfLine("501 Syntax error in MAIL command")
+		return
+	}
+	if size, ok := mailSizeParam(rest); ok && size > s.srv.MaxMessageSize {
+		s.tp.PrintfLine("552 Message size exceeds fixed maximum message size")
+		return
+	}
+	s.resetTransaction()
+	s.mailFrom = addr
+	s.state = stateMail
+	s.tp.PrintfLine("250 OK")
+}
+
+func (s *session) handleRcpt(rest string) {
+	if s.state != stateMail && s.state != stateRcpt {
+		s.tp.PrintfLine("503 Bad sequence of commands")
+		return
+	}
+	addr, ok := parseMailParam(rest, "TO:")
+	if !ok {
+		s.tp.PrintfLine("501 Syntax error in RCPT command")
+		return
+	}
+	s.rcptTo = append(s.rcptTo, addr)
+	s.state = stateRcpt
+	s.tp.PrintfLine("250 OK")
+}
+
+func (s *session) handleData() {
+	if s.state != stateRcpt {
+		s.tp.PrintfLine("503 Bad sequence of commands")
+		return
+	}
+	s.tp.PrintfLine("354 Start mail input; end with <CRLF>.<CRLF>")
+	dr := s.tp.DotReader()
+	raw, err := io.ReadAll(io.LimitReader(dr, int64(s.srv.MaxMessageSize)+1))
+	if err != nil {
+		s.tp.PrintfLine("451 Error reading message data")
+		s.resetTransaction()
+		return
+	}
+	if len(raw) > s.srv.MaxMessageSize {
+		// Drain whatever's left of the dot-encoded block so the connection
+		// stays framed correctly for the client's next command.
+		io.Copy(io.Discard, dr)
+		s.tp.PrintfLine("552 Message size exceeds fixed maximum message size")
+		s.resetTransaction()
+		return
+	}
+	if _, err := s.srv.Mailbox.Deliver(s.mailFrom, s.rcptTo, raw); err != nil {
+		s.tp.PrintfLine("451 Requested action aborted: local error in processing")
+		s.resetTransaction()
+		return
+	}
+	s.tp.PrintfLine("250 OK: message accepted")
+	s.resetTransaction()
+}
+
+func (s *session) resetTransaction() {
+	s.mailFrom = ""
+	s.rcptTo = nil
+	s.state = stateReady
+}
+
+func (s *session) handleStartTLS() {
+	if s.srv.TLSConfig == nil {
+		s.tp.PrintfLine("454 TLS not available")
+		return
+	}
+	if s.tls {
+		s.tp.PrintfLine("503 Already running in TLS")
+		return
+	}
+	s.tp.PrintfLine("220 Ready to start TLS")
+	tlsConn := tls.Server(s.conn, s.srv.TLSConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		return
+	}
+	s.conn = tlsConn
+	s.tp = textproto.NewConn(tlsConn)
+	s.tls = true
+	s.state = stateGreeting
+	s.heloName = ""
+}
+
+func (s *session) handleAuth(rest string) {
+	parts := strings.Fields(rest)
+	if len(parts) == 0 {
+		s.tp.PrintfLine("501 Syntax error in AUTH command")
+		return
+	}
+	mechanism := strings.ToUpper(parts[0])
+	switch mechanism {
+	case "PLAIN":
+		var blob string
+		if len(parts) == 2 {
+			blob = parts[1]
+		} else {
+			s.tp.PrintfLine("334 ")
+			line, err := s.tp.ReadLine()
+			if err != nil {
+				return
+			}
+			blob = line
+		}
+		user, ok := decodeAuthPlain(blob)
+		if !ok {
+			s.tp.PrintfLine("535 Authentication failed")
+			return
+		}
+		s.authedUser = user
+		s.tp.PrintfLine("235 Authentication successful")
+	case "LOGIN":
+		s.tp.PrintfLine("334 VXNlcm5hbWU6") // base64("Username:")
+		userB64, err := s.tp.ReadLine()
+		if err != nil {
+			return
+		}
+		s.tp.PrintfLine("334 UGFzc3dvcmQ6") // base64("Password:")
+		if _, err := s.tp.ReadLine(); err != nil {
+			return
+		}
+		user, err := base64.StdEncoding.DecodeString(userB64)
+		if err != nil || len(user) == 0 {
+			s.tp.PrintfLine("535 Authentication failed")
+			return
+		}
+		s.authedUser = string(user)
+		s.tp.PrintfLine("235 Authentication successful")
+	default:
+		s.tp.PrintfLine("504 Unrecognized authentication mechanism")
+	}
+}
+
+// decodeAuthPlain decodes a base64 "\x00user\x00password" blob as defined
+// by RFC 4616, returning the authentication identity.
+func decodeAuthPlain(blob string) (user string, ok bool) {
+	raw, err := base64.StdEncoding.DecodeString(blob)
+	if err != nil {
+		return "", false
+	}
+	parts := strings.SplitN(string(raw), "\x00", 3)
+	if len(parts) != 3 {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// splitCommand separates the verb from its argument, e.g.
+// "MAIL FROM:<a@b>" -> ("MAIL", "FROM:<a@b>").
+func splitCommand(line string) (verb, rest string) {
+	line = strings.TrimRight(line, "\r\n")
+	idx := strings.IndexByte(line, ' ')
+	if idx < 0 {
+		return line, ""
+	}
+	return line[:idx], strings.TrimSpace(line[idx+1:])
+}
+
+// parseMailParam extracts the address from a "FROM:<addr> PARAM=VAL..." or
+// "TO:<addr>" argument, stripping SIZE/BODY parameters.
+func parseMailParam(rest, prefix string) (string, bool) {
+	if !strings.HasPrefix(strings.ToUpper(rest), prefix) {
+		return "", false
+	}
+	rest = rest[len(prefix):]
+	end := strings.IndexByte(rest, ' ')
+	addrPart := rest
+	if end >= 0 {
+		addrPart = rest[:end]
+	}
+	addrPart = strings.TrimSpace(addrPart)
+	addrPart = strings.TrimPrefix(addrPart, "<")
+	addrPart = strings.TrimSuffix(addrPart, ">")
+	return addrPart, true
+}
+
+// mailSizeParam extracts the SIZE=<n> parameter from a MAIL FROM argument,
+// per RFC 1870, so the server can reject an over-limit message before
+// wasting a full DATA round trip on it.
+func mailSizeParam(rest string) (int, bool) {
+	for _, field := range strings.Fields(rest) {
+		if !strings.HasPrefix(strings.ToUpper(field), "SIZE=") {
+			continue
+		}
+		n, err := strconv.Atoi(field[len("SIZE="):])
+		if err != nil {
+			return 0, false
+		}
+		return n, true
+	}
+	return 0, false
+}
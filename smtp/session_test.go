@@ -0,0 +1,131 @@
+package smtp
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+)
+
+// testClient wraps the client end of an in-memory pipe with helpers for
+// sending a command line and reading back the single-line response.
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (c *testClient) readLine() string {
+	c.t.Helper()
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		c.t.Fatalf("read response: %v", err)
+	}
+	return strings.TrimRight(line, "\r\n")
+}
+
+func (c *testClient) send(cmd string) string {
+	c.t.Helper()
+	if _, err := c.conn.Write([]byte(cmd + "\r\n")); err != nil {
+		c.t.Fatalf("write %q: %v", cmd, err)
+	}
+	return c.readLine()
+}
+
+// newTestSession wires a session to one end of an in-memory pipe, starts it
+// serving in the background, and hands back the other end for tests to
+// write commands to and read responses from.
+func newTestSession(t *testing.T, srv *Server, requireAuth bool) *testClient {
+	t.Helper()
+	serverConn, clientConn := net.Pipe()
+	t.Cleanup(func() { clientConn.Close() })
+	s := newSession(srv, serverConn, requireAuth)
+	go s.serve()
+	return &testClient{t: t, conn: clientConn, r: bufio.NewReader(clientConn)}
+}
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	mailbox, err := NewMailbox(10, "")
+	if err != nil {
+		t.Fatalf("NewMailbox: %v", err)
+	}
+	return NewServer("mail.example.com", mailbox, nil)
+}
+
+func TestSessionCommandSequencing(t *testing.T) {
+	c := newTestSession(t, newTestServer(t), false)
+
+	if greeting := c.readLine(); !strings.HasPrefix(greeting, "220 ") {
+		t.Fatalf("greeting = %q, want 220 banner", greeting)
+	}
+
+	if resp := c.send("RCPT TO:<bob@example.com>"); !strings.HasPrefix(resp, "503") {
+		t.Fatalf("RCPT before MAIL = %q, want 503", resp)
+	}
+	if resp := c.send("DATA"); !strings.HasPrefix(resp, "503") {
+		t.Fatalf("DATA before MAIL/RCPT = %q, want 503", resp)
+	}
+	if resp := c.send("HELO client.example.com"); !strings.HasPrefix(resp, "250") {
+		t.Fatalf("HELO = %q, want 250", resp)
+	}
+	if resp := c.send("MAIL FROM:<alice@example.com>"); !strings.HasPrefix(resp, "250") {
+		t.Fatalf("MAIL FROM = %q, want 250", resp)
+	}
+	if resp := c.send("RCPT TO:<bob@example.com>"); !strings.HasPrefix(resp, "250") {
+		t.Fatalf("RCPT TO = %q, want 250", resp)
+	}
+	if resp := c.send("DATA"); !strings.HasPrefix(resp, "354") {
+		t.Fatalf("DATA after MAIL/RCPT = %q, want 354", resp)
+	}
+}
+
+func TestSessionRequiresAuthForMail(t *testing.T) {
+	c := newTestSession(t, newTestServer(t), true)
+
+	c.readLine() // greeting
+	c.send("HELO client.example.com")
+	if resp := c.send("MAIL FROM:<alice@example.com>"); !strings.HasPrefix(resp, "530") {
+		t.Fatalf("MAIL FROM without auth = %q, want 530", resp)
+	}
+}
+
+func TestSessionRejectsOversizeMailFromSizeParam(t *testing.T) {
+	srv := newTestServer(t)
+	srv.MaxMessageSize = 100
+	c := newTestSession(t, srv, false)
+
+	c.readLine() // greeting
+	c.send("HELO client.example.com")
+	if resp := c.send("MAIL FROM:<alice@example.com> SIZE=1000"); !strings.HasPrefix(resp, "552") {
+		t.Fatalf("MAIL FROM SIZE=1000 with limit 100 = %q, want 552", resp)
+	}
+}
+
+func TestSessionRejectsOversizeData(t *testing.T) {
+	srv := newTestServer(t)
+	srv.MaxMessageSize = 10
+	c := newTestSession(t, srv, false)
+
+	c.readLine() // greeting
+	c.send("HELO client.example.com")
+	c.send("MAIL FROM:<alice@example.com>")
+	c.send("RCPT TO:<bob@example.com>")
+	if resp := c.send("DATA"); !strings.HasPrefix(resp, "354") {
+		t.Fatalf("DATA = %q, want 354", resp)
+	}
+
+	body := strings.Repeat("x", srv.MaxMessageSize*2)
+	if _, err := c.conn.Write([]byte(body + "\r\n.\r\n")); err != nil {
+		t.Fatalf("write body: %v", err)
+	}
+	if resp := c.readLine(); !strings.HasPrefix(resp, "552") {
+		t.Fatalf("oversize DATA = %q, want 552", resp)
+	}
+
+	// The connection must still be usable afterward: the server has to
+	// have drained the rest of the dot-encoded block to stay in sync.
+	if resp := c.send("NOOP"); !strings.HasPrefix(resp, "250") {
+		t.Fatalf("NOOP after oversize DATA = %q, want 250", resp)
+	}
+}
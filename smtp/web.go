@@ -0,0 +1,89 @@
+package smtp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/mail"
+	"strings"
+)
+
+var inboxTemplate = template.Must(template.New("inbox").Parse(`<!DOCTYPE html>
+<html><head><title>simple-server mailbox</title></head>
+<body>
+<h1>Mailbox</h1>
+<ul>
+{{range .}}<li><a href="/mailbox/{{.ID}}">{{.Received.Format "2006-01-02 15:04:05"}} - {{.From}} -&gt; {{range .To}}{{.}} {{end}} - {{.Subject}}</a></li>
+{{else}}<li>(empty)</li>
+{{end}}
+</ul>
+</body></html>`))
+
+// Handler returns an http.Handler serving the mailbox under the given
+// prefix (e.g. "/mailbox"): prefix alone lists messages as HTML,
+// prefix+"/"+id shows one message, and prefix+"?format=json" returns the
+// JSON listing.
+func (mb *Mailbox) Handler(prefix string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(prefix, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(mb.List())
+			return
+		}
+		inboxTemplate.Execute(w, mb.List())
+	})
+	mux.HandleFunc(prefix+"/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, prefix+"/")
+		msg, ok := mb.Get(id)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		renderMessage(w, msg)
+	})
+	return mux
+}
+
+// renderMessage writes an HTML view of msg, including a listing of any
+// MIME attachments found in the body.
+func renderMessage(w http.ResponseWriter, msg *Message) {
+	fmt.Fprintf(w, "<html><body><h1>%s</h1><p>From: %s<br>To: %s<br>Date: %s</p><pre>\n",
+		template.HTMLEscapeString(msg.Subject),
+		template.HTMLEscapeString(msg.From),
+		template.HTMLEscapeString(strings.Join(msg.To, ", ")),
+		msg.Received.Format("2006-01-02 15:04:05"))
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(msg.Raw))
+	if err != nil {
+		fmt.Fprintf(w, "%s</pre></body></html>", template.HTMLEscapeString(string(msg.Raw)))
+		return
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(parsed.Body)
+		fmt.Fprintf(w, "%s</pre></body></html>", template.HTMLEscapeString(buf.String()))
+		return
+	}
+
+	fmt.Fprint(w, "</pre><h2>Parts</h2><ul>")
+	mr := multipart.NewReader(parsed.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		name := part.FileName()
+		if name == "" {
+			name = part.Header.Get("Content-Type")
+		}
+		fmt.Fprintf(w, "<li>%s</li>", template.HTMLEscapeString(name))
+	}
+	fmt.Fprint(w, "</ul></body></html>")
+}